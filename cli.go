@@ -2,15 +2,19 @@ package cbcli
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/codingbeard/cbutil"
 	"github.com/robfig/cron/v3"
 	"log"
+	"math/rand"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,6 +24,18 @@ type Task interface {
 	Run() error
 }
 
+// ContextualTask is an optional, context-aware alternative to Task.Run. When a task
+// implements it, RunTask calls RunContext(ctx) instead of the legacy Run(), and the
+// task can observe cancellation triggered by StopTask, StopAllTasks, or a hard
+// ErrorAfterDuration cancel.
+//
+// RunContext is a distinct method from Task.Run so a type can implement both without
+// a signature conflict; tasks which want context-awareness should implement both Run
+// (even as a no-op/unreachable body) and RunContext.
+type ContextualTask interface {
+	RunContext(ctx context.Context) error
+}
+
 type ScheduledTask interface {
 	GetSchedule() string
 }
@@ -32,6 +48,30 @@ type ErrorAfterDurationTask interface {
 	GetErrorAfterDuration() time.Duration
 }
 
+// CancelOnTimeoutTask lets an ErrorAfterDurationTask opt into having its context
+// hard-cancelled once GetErrorAfterDuration elapses, instead of only logging a
+// warning (the legacy behaviour, still the default for tasks which don't implement
+// this interface).
+type CancelOnTimeoutTask interface {
+	ErrorAfterDurationTask
+	CancelOnTimeout() bool
+}
+
+// RetryableTask lets RunTask retry a failing task with exponential backoff instead of
+// returning its first error.
+type RetryableTask interface {
+	GetMaxAttempts() int
+	GetBackoff() time.Duration
+}
+
+// CircuitBreakableTask lets the container pause dispatching/running a task after
+// GetFailureThreshold consecutive failures, skipping it for GetCooldown before
+// resuming automatically (or resuming immediately on its next success).
+type CircuitBreakableTask interface {
+	GetFailureThreshold() int
+	GetCooldown() time.Duration
+}
+
 type Logger interface {
 	InfoF(category string, message string, args ...interface{})
 	Write(message []byte) (n int, e error)
@@ -84,22 +124,53 @@ type Config interface {
 	where group and name are replaced with the task's returned values
 	*/
 	GetRequiredBool(path string) (bool, error)
+
+	/**
+	first return type is the string map defined at the path, e.g. cbcli.env or
+	cbcli.group.name.env
+	second return type is an error when the config path is not defined
+	*/
+	GetStringMap(path string) (map[string]string, error)
+}
+
+// TaskEnvProvider lets a task inject its own environment variables into dispatched
+// subprocesses programmatically, instead of (or alongside) config-driven env. It is
+// applied last, so it can override both Config and SetDispatchEnvironment.
+type TaskEnvProvider interface {
+	Env() []string
 }
 
 var TaskNotFound = errors.New("task not found")
 
 type TaskContainer struct {
-	tasks        []Task
-	logger       Logger
-	errors       ErrorHandler
-	config       Config
-	dispatchEnvs []string
+	tasks         []Task
+	logger        Logger
+	errors        ErrorHandler
+	config        Config
+	dispatchEnvs  []string
+	scheduler     Scheduler
+	runningMu     sync.Mutex
+	running       map[string]context.CancelFunc
+	failuresMu    sync.Mutex
+	failures      map[string]*taskFailureState
+	apiToken      string
+	runCounter    uint64
+	runsMu        sync.Mutex
+	runs          map[string]*runRecord
+	activeRunLogs map[string]*logRingBuffer
+	metrics       metricsRecorder
+}
+
+type taskFailureState struct {
+	consecutiveFailures int
+	pausedUntil         time.Time
 }
 
 func New() *TaskContainer {
 	return &TaskContainer{
-		logger: defaultLogger{},
-		errors: defaultErrorHandler{},
+		logger:  defaultLogger{},
+		errors:  defaultErrorHandler{},
+		metrics: noopMetricsRecorder{},
 	}
 }
 
@@ -123,6 +194,48 @@ func (t *TaskContainer) SetDispatchEnvironment(envs []string) {
 	t.dispatchEnvs = envs
 }
 
+// buildTaskEnv assembles the environment for a dispatched subprocess, merging in
+// order (each layer able to override the previous): the parent process's own
+// environment, SetDispatchEnvironment's envs, the "cbcli.env" globals from Config,
+// the per-task "cbcli.<group>.<name>.env" from Config, and finally task.Env() if the
+// task implements TaskEnvProvider.
+func (t *TaskContainer) buildTaskEnv(task Task) []string {
+	env := append([]string{}, os.Environ()...)
+	env = append(env, t.dispatchEnvs...)
+
+	if t.config != nil {
+		if globals, e := t.config.GetStringMap("cbcli.env"); e == nil {
+			env = append(env, envMapToSlice(globals)...)
+		}
+
+		path := fmt.Sprintf("cbcli.%s.%s.env", task.GetGroup(), task.GetName())
+		if perTask, e := t.config.GetStringMap(path); e == nil {
+			env = append(env, envMapToSlice(perTask)...)
+		}
+	}
+
+	if envProvider, ok := task.(TaskEnvProvider); ok {
+		env = append(env, envProvider.Env()...)
+	}
+
+	return env
+}
+
+func envMapToSlice(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", key, m[key]))
+	}
+
+	return env
+}
+
 func (t *TaskContainer) Execute() error {
 	if len(os.Args) > 2 && os.Args != nil {
 		group := os.Args[1]
@@ -157,35 +270,113 @@ func (t *TaskContainer) RunTask(group, name string) error {
 	for _, task := range t.tasks {
 		if task.GetGroup() == group && task.GetName() == name {
 
-			trueVariable := true
-			falseVariable := false
-			var running *bool
-			if errorAfterTask, ok := task.(ErrorAfterDurationTask); ok {
-				go func() {
-					elapsed := time.Duration(0)
-					for true {
-						cbutil.Sleep(time.Second)
-						elapsed += time.Second
-
-						if elapsed >= errorAfterTask.GetErrorAfterDuration() {
-							if *running {
-								t.errors.Error(fmt.Errorf(
-									"task still running after expected duration: %s:%s %ds",
-									task.GetGroup(),
-									task.GetName(),
-									int(errorAfterTask.GetErrorAfterDuration()/time.Second),
-								))
+			if paused, until := t.isPaused(group, name); paused {
+				e := fmt.Errorf("task paused (%s:%s) until %s", group, name, until.Format(time.RFC3339))
+				t.logger.InfoF("CLI", e.Error())
+				return e
+			}
+
+			stopCtx, stopCancel := context.WithCancel(context.Background())
+			key := runningTaskKey(group, name)
+			t.addRunningTask(key, stopCancel)
+			defer t.removeRunningTask(key)
+
+			t.logger.InfoF("CLI", "Running task (%s:%s)", task.GetGroup(), task.GetName())
+			t.metrics.setRunning(task.GetGroup(), task.GetName(), true)
+			t.pingHeartbeat(task, "/start")
+			startedAt := time.Now()
+
+			attempts := 1
+			var backoff time.Duration
+			if retryableTask, ok := task.(RetryableTask); ok {
+				if retryableTask.GetMaxAttempts() > attempts {
+					attempts = retryableTask.GetMaxAttempts()
+				}
+				backoff = retryableTask.GetBackoff()
+			}
+
+			var e error
+			for attempt := 1; attempt <= attempts; attempt++ {
+				// Each attempt gets its own context, derived from stopCtx so
+				// StopTask/StopAllTasks still cancels every attempt, but a watchdog
+				// cancel (below) or a prior attempt's cancellation doesn't carry over
+				// and doom the next retry to fail instantly.
+				attemptCtx, attemptCancel := context.WithCancel(stopCtx)
+
+				done := make(chan struct{})
+				if errorAfterTask, ok := task.(ErrorAfterDurationTask); ok {
+					go func() {
+						elapsed := time.Duration(0)
+						for {
+							select {
+							case <-done:
+								return
+							default:
+							}
+
+							cbutil.Sleep(time.Second)
+							elapsed += time.Second
+
+							if elapsed >= errorAfterTask.GetErrorAfterDuration() {
+								select {
+								case <-done:
+								default:
+									t.errors.Error(fmt.Errorf(
+										"task still running after expected duration: %s:%s %ds",
+										task.GetGroup(),
+										task.GetName(),
+										int(errorAfterTask.GetErrorAfterDuration()/time.Second),
+									))
+									if cancelTask, ok := task.(CancelOnTimeoutTask); ok && cancelTask.CancelOnTimeout() {
+										attemptCancel()
+									}
+								}
+								return
 							}
-							break
 						}
-					}
-				}()
+					}()
+				}
+
+				if contextualTask, ok := task.(ContextualTask); ok {
+					e = contextualTask.RunContext(attemptCtx)
+				} else {
+					e = task.Run()
+				}
+				close(done)
+				attemptCancel()
+
+				if e == nil || attempt == attempts {
+					break
+				}
+
+				wait := backoffWithJitter(backoff, attempt)
+				t.logger.InfoF(
+					"CLI",
+					"task failed (%s:%s), retrying in %s (attempt %d/%d): %s",
+					task.GetGroup(),
+					task.GetName(),
+					wait,
+					attempt+1,
+					attempts,
+					e.Error(),
+				)
+				cbutil.Sleep(wait)
+			}
+
+			t.recordTaskResult(task, e == nil)
+
+			status := "success"
+			if e != nil {
+				status = "failure"
+			}
+			t.metrics.observeRun(task.GetGroup(), task.GetName(), status, time.Since(startedAt))
+			t.metrics.setRunning(task.GetGroup(), task.GetName(), false)
+			if e != nil {
+				t.pingHeartbeat(task, "/fail/1")
+			} else {
+				t.pingHeartbeat(task, "")
 			}
 
-			t.logger.InfoF("CLI", "Running task (%s:%s)", task.GetGroup(), task.GetName())
-			running = &trueVariable
-			e := task.Run()
-			running = &falseVariable
 			t.logger.InfoF("CLI", "Finished running task (%s:%s)", task.GetGroup(), task.GetName())
 			return e
 		}
@@ -194,8 +385,139 @@ func (t *TaskContainer) RunTask(group, name string) error {
 	return TaskNotFound
 }
 
-func (t *TaskContainer) DispatchTasks() {
-	crontab := cron.New()
+func runningTaskKey(group, name string) string {
+	return group + ":" + name
+}
+
+func (t *TaskContainer) addRunningTask(key string, cancel context.CancelFunc) {
+	t.runningMu.Lock()
+	defer t.runningMu.Unlock()
+
+	if t.running == nil {
+		t.running = map[string]context.CancelFunc{}
+	}
+	t.running[key] = cancel
+}
+
+func (t *TaskContainer) removeRunningTask(key string) {
+	t.runningMu.Lock()
+	defer t.runningMu.Unlock()
+
+	delete(t.running, key)
+}
+
+// StopTask cancels the context of a task currently running via RunTask. Tasks which
+// only implement the legacy Task.Run() are unaffected by cancellation, since they
+// never observe the context. Returns TaskNotFound if group:name is not running.
+func (t *TaskContainer) StopTask(group, name string) error {
+	t.runningMu.Lock()
+	cancel, ok := t.running[runningTaskKey(group, name)]
+	t.runningMu.Unlock()
+
+	if !ok {
+		return TaskNotFound
+	}
+
+	cancel()
+
+	return nil
+}
+
+// StopAllTasks cancels the context of every task currently running via RunTask.
+func (t *TaskContainer) StopAllTasks() {
+	t.runningMu.Lock()
+	defer t.runningMu.Unlock()
+
+	for _, cancel := range t.running {
+		cancel()
+	}
+}
+
+// isPaused reports whether a task's circuit breaker is currently open.
+func (t *TaskContainer) isPaused(group, name string) (bool, time.Time) {
+	t.failuresMu.Lock()
+	defer t.failuresMu.Unlock()
+
+	state, ok := t.failures[runningTaskKey(group, name)]
+	if !ok || state.pausedUntil.IsZero() || time.Now().After(state.pausedUntil) {
+		return false, time.Time{}
+	}
+
+	return true, state.pausedUntil
+}
+
+// recordTaskResult feeds a run's outcome into task's circuit breaker, if it has one.
+// A success resets the consecutive failure count and closes the breaker; enough
+// consecutive failures opens it for GetCooldown.
+func (t *TaskContainer) recordTaskResult(task Task, success bool) {
+	breaker, ok := task.(CircuitBreakableTask)
+	if !ok {
+		return
+	}
+
+	key := runningTaskKey(task.GetGroup(), task.GetName())
+
+	t.failuresMu.Lock()
+	defer t.failuresMu.Unlock()
+
+	if t.failures == nil {
+		t.failures = map[string]*taskFailureState{}
+	}
+	state, ok := t.failures[key]
+	if !ok {
+		state = &taskFailureState{}
+		t.failures[key] = state
+	}
+
+	if success {
+		state.consecutiveFailures = 0
+		state.pausedUntil = time.Time{}
+		return
+	}
+
+	state.consecutiveFailures++
+	if state.consecutiveFailures >= breaker.GetFailureThreshold() {
+		state.pausedUntil = time.Now().Add(breaker.GetCooldown())
+		t.logger.InfoF(
+			"CLI",
+			"task paused (%s:%s) after %d consecutive failures, resuming at %s",
+			task.GetGroup(),
+			task.GetName(),
+			state.consecutiveFailures,
+			state.pausedUntil.Format(time.RFC3339),
+		)
+	}
+}
+
+const maxTaskBackoff = 5 * time.Minute
+
+// backoffWithJitter returns the exponential backoff for a given retry attempt
+// (1-indexed), capped at maxTaskBackoff, with up to 50% jitter to avoid every
+// instance of a dispatched task retrying in lockstep.
+func backoffWithJitter(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+
+	backoff := base * time.Duration(int64(1)<<uint(attempt-1))
+	if backoff <= 0 || backoff > maxTaskBackoff {
+		backoff = maxTaskBackoff
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
+
+type enabledScheduledTask struct {
+	task          Task
+	scheduledTask ScheduledTask
+}
+
+// enabledScheduledTasks returns the tasks which declare a schedule, are not set to
+// "manual", and are enabled in config (if a Config is set). It is shared between
+// DispatchTasks and the Scheduler implementations so they agree on what "scheduled"
+// means.
+func (t *TaskContainer) enabledScheduledTasks() []enabledScheduledTask {
+	var scheduled []enabledScheduledTask
 
 	for taskKey := range t.tasks {
 		task := t.tasks[taskKey]
@@ -212,7 +534,36 @@ func (t *TaskContainer) DispatchTasks() {
 				continue
 			}
 		}
+
+		scheduled = append(scheduled, enabledScheduledTask{task: task, scheduledTask: scheduledTask})
+	}
+
+	return scheduled
+}
+
+func (t *TaskContainer) DispatchTasks() {
+	if t.scheduler != nil && t.scheduler.Name() != SchedulerInProcess {
+		t.logger.InfoF("CLI", "Scheduler %s is active, skipping in-process cron dispatch", t.scheduler.Name())
+		return
+	}
+
+	crontab := cron.New()
+
+	for _, scheduled := range t.enabledScheduledTasks() {
+		task := scheduled.task
+		scheduledTask := scheduled.scheduledTask
 		_, e := crontab.AddFunc(scheduledTask.GetSchedule(), func() {
+			if paused, until := t.isPaused(task.GetGroup(), task.GetName()); paused {
+				t.logger.InfoF(
+					"CLI",
+					"task paused (%s:%s), skipping dispatch until %s",
+					task.GetGroup(),
+					task.GetName(),
+					until.Format(time.RFC3339),
+				)
+				return
+			}
+
 			t.logger.InfoF("CLI", "Dispatching task (%s:%s)", task.GetGroup(), task.GetName())
 			isGoroutineTask := false
 			goroutineTask, isGoroutineConfigurableTask := task.(GoroutineConfigurableTask)
@@ -233,10 +584,11 @@ func (t *TaskContainer) DispatchTasks() {
 					t.errors.Error(e)
 				}
 				cmd := exec.Command(executable, task.GetGroup(), task.GetName())
-				cmd.Env = t.dispatchEnvs
+				cmd.Env = t.buildTaskEnv(task)
 				cmd.Stderr = t.logger
 				cmd.Stderr = t.logger
 				e = cmd.Run()
+				t.recordTaskResult(task, e == nil)
 				if e != nil {
 					t.errors.Error(e)
 				}
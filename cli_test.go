@@ -0,0 +1,38 @@
+package cbcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffWithJitterDefaultsBaseAndCaps(t *testing.T) {
+	if got := backoffWithJitter(0, 1); got <= 0 || got > maxTaskBackoff {
+		t.Errorf("backoffWithJitter(0, 1) = %s, want within (0, %s]", got, maxTaskBackoff)
+	}
+
+	if got := backoffWithJitter(time.Hour, 10); got > maxTaskBackoff {
+		t.Errorf("backoffWithJitter(time.Hour, 10) = %s, want capped at %s", got, maxTaskBackoff)
+	}
+}
+
+func TestBackoffWithJitterGrowsWithAttempt(t *testing.T) {
+	base := 100 * time.Millisecond
+
+	// backoffWithJitter returns between half and all of base*2^(attempt-1), so each
+	// attempt's result is at least half of its un-jittered backoff, a floor which
+	// doubles with every attempt.
+	minFor := func(attempt int) time.Duration {
+		backoff := base * time.Duration(int64(1)<<uint(attempt-1))
+		if backoff > maxTaskBackoff {
+			backoff = maxTaskBackoff
+		}
+		return backoff / 2
+	}
+
+	for attempt := 1; attempt < 4; attempt++ {
+		got := backoffWithJitter(base, attempt)
+		if got < minFor(attempt) {
+			t.Errorf("backoffWithJitter(base, %d) = %s, want >= %s", attempt, got, minFor(attempt))
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cbcli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The conversions below only support plain 5-field cron expressions
+// (minute hour dom month dow) with literal numbers, "*" and "*/N" steps. Anything
+// more expressive (lists, ranges, names) is rejected rather than silently
+// approximated, since a wrong native schedule is worse than a clear error at
+// install time.
+
+type cronFields struct {
+	minute, hour, dom, month, dow string
+}
+
+func parseCron(schedule string) (cronFields, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return cronFields{}, fmt.Errorf("unsupported cron expression %q: expected 5 fields", schedule)
+	}
+
+	return cronFields{
+		minute: fields[0],
+		hour:   fields[1],
+		dom:    fields[2],
+		month:  fields[3],
+		dow:    fields[4],
+	}, nil
+}
+
+var literalFieldPattern = regexp.MustCompile(`^\d+$`)
+var stepFieldPattern = regexp.MustCompile(`^\*/\d+$`)
+
+// requireLiteralField rejects anything other than a plain, non-negative integer for a
+// cron field, so callers can't turn a list/range/name (e.g. "9-17") into a bogus
+// native schedule.
+func requireLiteralField(schedule, field, name string) error {
+	if !literalFieldPattern.MatchString(field) {
+		return fmt.Errorf("unsupported cron expression %q: %s must be a literal number", schedule, name)
+	}
+
+	return nil
+}
+
+// requireStepField rejects anything other than "*/N" for a cron field.
+func requireStepField(schedule, field, name string) error {
+	if !stepFieldPattern.MatchString(field) {
+		return fmt.Errorf("unsupported cron expression %q: %s must be \"*/N\"", schedule, name)
+	}
+
+	return nil
+}
+
+// cronToOnCalendar converts a simple cron expression into a systemd OnCalendar value.
+// dom/month/dow beyond "*" are rejected as unsupported: systemd's DayOfWeek field uses
+// names (Mon..Sun), not cron's 0-6 numbers, so cron's dow can't be passed through
+// as-is, and translating it is more than this converter is for.
+func cronToOnCalendar(schedule string) (string, error) {
+	f, e := parseCron(schedule)
+	if e != nil {
+		return "", e
+	}
+	if f.dom != "*" || f.month != "*" || f.dow != "*" {
+		return "", fmt.Errorf("unsupported cron expression %q: systemd scheduler only supports daily HH:MM and step minute/hour schedules", schedule)
+	}
+
+	hour, e := onCalendarField(schedule, f.hour, "hour")
+	if e != nil {
+		return "", e
+	}
+	minute, e := onCalendarField(schedule, f.minute, "minute")
+	if e != nil {
+		return "", e
+	}
+
+	return fmt.Sprintf("*-*-* %s:%s:00", hour, minute), nil
+}
+
+// onCalendarField validates and translates a single cron hour/minute field into its
+// systemd OnCalendar equivalent: "*" passes through, "*/N" becomes systemd's "0/N"
+// step syntax, and anything else must be a literal number.
+func onCalendarField(schedule, field, name string) (string, error) {
+	if field == "*" {
+		return "*", nil
+	}
+	if strings.HasPrefix(field, "*/") {
+		if e := requireStepField(schedule, field, name); e != nil {
+			return "", e
+		}
+		return "0/" + strings.TrimPrefix(field, "*/"), nil
+	}
+	if e := requireLiteralField(schedule, field, name); e != nil {
+		return "", e
+	}
+
+	return field, nil
+}
+
+// cronToCalendarIntervalPlist converts a simple cron expression into a launchd
+// StartCalendarInterval dict. Only minute/hour are expressible for "every day" style
+// schedules; dom/month/dow beyond "*" are rejected as unsupported.
+func cronToCalendarIntervalPlist(schedule string) (string, error) {
+	f, e := parseCron(schedule)
+	if e != nil {
+		return "", e
+	}
+	if f.dom != "*" || f.month != "*" || f.dow != "*" || f.minute == "*" || f.hour == "*" {
+		return "", fmt.Errorf("unsupported cron expression %q: launchd scheduler only supports daily HH:MM schedules", schedule)
+	}
+	if e := requireLiteralField(schedule, f.hour, "hour"); e != nil {
+		return "", e
+	}
+	if e := requireLiteralField(schedule, f.minute, "minute"); e != nil {
+		return "", e
+	}
+
+	return fmt.Sprintf(
+		"\t<key>StartCalendarInterval</key>\n\t<dict>\n\t\t<key>Hour</key>\n\t\t<integer>%s</integer>\n\t\t<key>Minute</key>\n\t\t<integer>%s</integer>\n\t</dict>\n",
+		f.hour,
+		f.minute,
+	), nil
+}
+
+// cronToSchtasksArgs converts a simple cron expression into the /SC /ST /MO flags
+// `schtasks /Create` expects. Only daily "M H * * *" and every-N-minutes "*/N * * * *"
+// schedules are supported; a plain hourly "M * * * *" is rejected.
+func cronToSchtasksArgs(schedule string) ([]string, error) {
+	f, e := parseCron(schedule)
+	if e != nil {
+		return nil, e
+	}
+	if f.dom != "*" || f.month != "*" || f.dow != "*" {
+		return nil, fmt.Errorf("unsupported cron expression %q: taskscheduler only supports daily HH:MM and step minute schedules", schedule)
+	}
+
+	if f.minute != "*" && f.hour != "*" {
+		if e := requireLiteralField(schedule, f.hour, "hour"); e != nil {
+			return nil, e
+		}
+		if e := requireLiteralField(schedule, f.minute, "minute"); e != nil {
+			return nil, e
+		}
+		return []string{"/SC", "DAILY", "/ST", fmt.Sprintf("%02s:%02s", f.hour, f.minute)}, nil
+	}
+	if strings.HasPrefix(f.minute, "*/") && f.hour == "*" {
+		if e := requireStepField(schedule, f.minute, "minute"); e != nil {
+			return nil, e
+		}
+		return []string{"/SC", "MINUTE", "/MO", strings.TrimPrefix(f.minute, "*/")}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported cron expression %q: taskscheduler only supports daily HH:MM and step minute schedules", schedule)
+}
@@ -0,0 +1,108 @@
+package cbcli
+
+import "testing"
+
+func TestCronToOnCalendar(t *testing.T) {
+	cases := []struct {
+		schedule string
+		want     string
+	}{
+		{"0 9 * * *", "*-*-* 9:0:00"},
+		{"*/15 * * * *", "*-*-* *:0/15:00"},
+		{"0 */2 * * *", "*-*-* 0/2:0:00"},
+	}
+
+	for _, c := range cases {
+		got, e := cronToOnCalendar(c.schedule)
+		if e != nil {
+			t.Fatalf("cronToOnCalendar(%q) returned unexpected error: %s", c.schedule, e)
+		}
+		if got != c.want {
+			t.Errorf("cronToOnCalendar(%q) = %q, want %q", c.schedule, got, c.want)
+		}
+	}
+}
+
+func TestCronToOnCalendarRejectsUnsupportedFields(t *testing.T) {
+	cases := []string{
+		"0 9 * * 1",   // day-of-week
+		"0 1-5 * * *", // range
+		"0 9 1 * *",   // day-of-month
+		"0 9 * 6 *",   // month
+		"a b * * *",   // nonsense
+		"0 9 * *",     // wrong field count
+	}
+
+	for _, schedule := range cases {
+		if _, e := cronToOnCalendar(schedule); e == nil {
+			t.Errorf("cronToOnCalendar(%q) = nil error, want rejection", schedule)
+		}
+	}
+}
+
+func TestCronToCalendarIntervalPlist(t *testing.T) {
+	got, e := cronToCalendarIntervalPlist("30 9 * * *")
+	if e != nil {
+		t.Fatalf("cronToCalendarIntervalPlist returned unexpected error: %s", e)
+	}
+	want := "\t<key>StartCalendarInterval</key>\n\t<dict>\n\t\t<key>Hour</key>\n\t\t<integer>9</integer>\n\t\t<key>Minute</key>\n\t\t<integer>30</integer>\n\t</dict>\n"
+	if got != want {
+		t.Errorf("cronToCalendarIntervalPlist = %q, want %q", got, want)
+	}
+}
+
+func TestCronToCalendarIntervalPlistRejectsUnsupportedFields(t *testing.T) {
+	cases := []string{
+		"0 9-17 * * *", // range hour
+		"*/15 9 * * *", // step minute, not daily
+		"0 9 * * 1",    // day-of-week
+		"0 9 1 * *",    // day-of-month
+		"* 9 * * *",    // wildcard minute
+	}
+
+	for _, schedule := range cases {
+		if _, e := cronToCalendarIntervalPlist(schedule); e == nil {
+			t.Errorf("cronToCalendarIntervalPlist(%q) = nil error, want rejection", schedule)
+		}
+	}
+}
+
+func TestCronToSchtasksArgs(t *testing.T) {
+	cases := []struct {
+		schedule string
+		want     []string
+	}{
+		{"30 9 * * *", []string{"/SC", "DAILY", "/ST", "09:30"}},
+		{"*/15 * * * *", []string{"/SC", "MINUTE", "/MO", "15"}},
+	}
+
+	for _, c := range cases {
+		got, e := cronToSchtasksArgs(c.schedule)
+		if e != nil {
+			t.Fatalf("cronToSchtasksArgs(%q) returned unexpected error: %s", c.schedule, e)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("cronToSchtasksArgs(%q) = %v, want %v", c.schedule, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("cronToSchtasksArgs(%q) = %v, want %v", c.schedule, got, c.want)
+			}
+		}
+	}
+}
+
+func TestCronToSchtasksArgsRejectsUnsupportedFields(t *testing.T) {
+	cases := []string{
+		"0 9-17 * * *", // range hour
+		"0 9 * * 1",    // day-of-week
+		"0 9 1 * *",    // day-of-month
+		"*/x * * * *",  // non-numeric step
+	}
+
+	for _, schedule := range cases {
+		if _, e := cronToSchtasksArgs(schedule); e == nil {
+			t.Errorf("cronToSchtasksArgs(%q) = nil error, want rejection", schedule)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package cbcli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const apiTokenHeader = "X-Cbcli-Token"
+
+const maxRunLogBytes = 1 << 20 // 1MiB per run, oldest bytes are dropped once exceeded
+
+// SetAPIToken configures the shared-secret token ServeHTTP requires on every request,
+// sent via the X-Cbcli-Token header. An empty token (the default) disables auth,
+// which is only appropriate when ServeHTTP is bound to a trusted interface.
+func (t *TaskContainer) SetAPIToken(token string) {
+	t.apiToken = token
+}
+
+// runRecord tracks one invocation of a task triggered through the HTTP control plane.
+type runRecord struct {
+	ID        string    `json:"id"`
+	Group     string    `json:"group"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Running   bool      `json:"running"`
+	buffer    *logRingBuffer
+}
+
+// ServeHTTP starts a small JSON API for listing, triggering, stopping and tailing the
+// logs of tasks. It installs a logger wrapper (once) that tees everything written
+// through the container's Logger into the in-memory buffer of whichever runs are
+// currently in flight, so GET /runs/{id}/logs can tail output as it happens. It blocks
+// serving requests until the listener errors, mirroring http.ListenAndServe.
+func (t *TaskContainer) ServeHTTP(addr string) error {
+	if _, alreadyWrapped := t.logger.(*httpTeeLogger); !alreadyWrapped {
+		t.logger = &httpTeeLogger{Logger: t.logger, container: t}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tasks", t.authorized(t.handleListTasks))
+	mux.HandleFunc("/tasks/", t.authorized(t.handleTaskAction))
+	mux.HandleFunc("/runs/", t.authorized(t.handleRunAction))
+
+	return http.ListenAndServe(addr, mux)
+}
+
+func (t *TaskContainer) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if t.apiToken != "" && r.Header.Get(apiTokenHeader) != t.apiToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+type taskSummary struct {
+	Group     string `json:"group"`
+	Name      string `json:"name"`
+	Schedule  string `json:"schedule,omitempty"`
+	Enabled   bool   `json:"enabled"`
+	LastRun   string `json:"lastRun,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	Running   bool   `json:"running"`
+}
+
+func (t *TaskContainer) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summaries := make([]taskSummary, 0, len(t.tasks))
+	for _, task := range t.tasks {
+		summary := taskSummary{
+			Group:   task.GetGroup(),
+			Name:    task.GetName(),
+			Enabled: t.isTaskEnabled(task),
+		}
+		if scheduledTask, ok := task.(ScheduledTask); ok {
+			summary.Schedule = scheduledTask.GetSchedule()
+		}
+
+		t.runningMu.Lock()
+		_, summary.Running = t.running[runningTaskKey(task.GetGroup(), task.GetName())]
+		t.runningMu.Unlock()
+
+		if last := t.lastRunFor(task.GetGroup(), task.GetName()); last != nil {
+			summary.LastRun = last.StartedAt.Format(time.RFC3339)
+			summary.LastError = last.Error
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (t *TaskContainer) isTaskEnabled(task Task) bool {
+	if t.config == nil {
+		return true
+	}
+
+	enabled, e := t.config.GetRequiredBool(fmt.Sprintf("cbcli.%s.%s", task.GetGroup(), task.GetName()))
+	if e != nil {
+		return true
+	}
+
+	return enabled
+}
+
+func (t *TaskContainer) lastRunFor(group, name string) *runRecord {
+	t.runsMu.Lock()
+	defer t.runsMu.Unlock()
+
+	var last *runRecord
+	for _, run := range t.runs {
+		if run.Group != group || run.Name != name {
+			continue
+		}
+		if last == nil || run.StartedAt.After(last.StartedAt) {
+			last = run
+		}
+	}
+
+	return last
+}
+
+// handleTaskAction serves POST /tasks/{group}/{name}/run and
+// POST /tasks/{group}/{name}/stop.
+func (t *TaskContainer) handleTaskAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/"), "/")
+	if len(parts) != 3 || r.Method != http.MethodPost {
+		http.Error(w, "expected POST /tasks/{group}/{name}/run|stop", http.StatusBadRequest)
+		return
+	}
+	group, name, action := parts[0], parts[1], parts[2]
+
+	var task Task
+	for _, candidate := range t.tasks {
+		if candidate.GetGroup() == group && candidate.GetName() == name {
+			task = candidate
+			break
+		}
+	}
+	if task == nil {
+		http.Error(w, TaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "run":
+		run := t.triggerRun(task)
+		writeJSON(w, http.StatusAccepted, run)
+	case "stop":
+		if e := t.StopTask(group, name); e != nil {
+			http.Error(w, e.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unknown action, expected run or stop", http.StatusBadRequest)
+	}
+}
+
+// triggerRun starts a task in a goroutine, recording its output and outcome under a
+// new run id, and returns immediately with the (still running) record.
+func (t *TaskContainer) triggerRun(task Task) *runRecord {
+	run := &runRecord{
+		ID:        fmt.Sprintf("%s-%s-%d", task.GetGroup(), task.GetName(), atomic.AddUint64(&t.runCounter, 1)),
+		Group:     task.GetGroup(),
+		Name:      task.GetName(),
+		StartedAt: time.Now(),
+		Running:   true,
+		buffer:    newLogRingBuffer(),
+	}
+
+	t.runsMu.Lock()
+	if t.runs == nil {
+		t.runs = map[string]*runRecord{}
+	}
+	t.runs[run.ID] = run
+	t.activateRunLog(run)
+	t.runsMu.Unlock()
+
+	go func() {
+		defer func() {
+			t.runsMu.Lock()
+			t.deactivateRunLog(run)
+			t.runsMu.Unlock()
+		}()
+
+		t.errors.Recover()
+		e := t.RunTask(task.GetGroup(), task.GetName())
+
+		t.runsMu.Lock()
+		run.EndedAt = time.Now()
+		run.Running = false
+		if e != nil {
+			run.Error = e.Error()
+		}
+		t.runsMu.Unlock()
+		run.buffer.close()
+	}()
+
+	return run
+}
+
+// handleRunAction serves GET /runs/{id}/logs and GET /runs/{id}/archive.
+func (t *TaskContainer) handleRunAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/runs/"), "/"), "/")
+	if len(parts) != 2 || r.Method != http.MethodGet {
+		http.Error(w, "expected GET /runs/{id}/logs|archive", http.StatusBadRequest)
+		return
+	}
+	runID, action := parts[0], parts[1]
+
+	t.runsMu.Lock()
+	run, ok := t.runs[runID]
+	t.runsMu.Unlock()
+	if !ok {
+		http.Error(w, "run not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "logs":
+		t.streamRunLogs(w, run)
+	case "archive":
+		t.archiveRun(w, run)
+	default:
+		http.Error(w, "unknown action, expected logs or archive", http.StatusBadRequest)
+	}
+}
+
+func (t *TaskContainer) streamRunLogs(w http.ResponseWriter, run *runRecord) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	flusher, canFlush := w.(http.Flusher)
+
+	writeChunk := func(p []byte) {
+		for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+
+	writeChunk(run.buffer.snapshot())
+	if !run.Running {
+		return
+	}
+
+	sub := run.buffer.subscribe()
+	defer run.buffer.unsubscribe(sub)
+
+	for chunk := range sub {
+		writeChunk(chunk)
+	}
+}
+
+func (t *TaskContainer) archiveRun(w http.ResponseWriter, run *runRecord) {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tar.gz", run.ID))
+
+	gzWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	metadata, _ := json.MarshalIndent(run, "", "  ")
+	_ = tarWriter.WriteHeader(&tar.Header{Name: "metadata.json", Mode: 0644, Size: int64(len(metadata))})
+	_, _ = tarWriter.Write(metadata)
+
+	logs := run.buffer.snapshot()
+	_ = tarWriter.WriteHeader(&tar.Header{Name: "run.log", Mode: 0644, Size: int64(len(logs))})
+	_, _ = tarWriter.Write(logs)
+
+	_ = tarWriter.Close()
+	_ = gzWriter.Close()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// httpTeeLogger wraps the container's configured Logger so that everything logged or
+// written through it is also fanned out to the buffer of every run currently active
+// through the HTTP control plane. Since Logger is a single process-wide sink, output
+// from multiple concurrently running HTTP-triggered tasks is interleaved across their
+// buffers - acceptable for the common case of one triggered run at a time.
+type httpTeeLogger struct {
+	Logger
+	container *TaskContainer
+}
+
+func (l *httpTeeLogger) Write(p []byte) (int, error) {
+	n, e := l.Logger.Write(p)
+	l.container.teeActiveRunLogs(p)
+	return n, e
+}
+
+func (l *httpTeeLogger) InfoF(category string, message string, args ...interface{}) {
+	l.Logger.InfoF(category, message, args...)
+	l.container.teeActiveRunLogs([]byte(fmt.Sprintf("%s: %s\n", category, fmt.Sprintf(message, args...))))
+}
+
+func (t *TaskContainer) teeActiveRunLogs(p []byte) {
+	t.runsMu.Lock()
+	active := make([]*logRingBuffer, 0, len(t.activeRunLogs))
+	for _, buffer := range t.activeRunLogs {
+		active = append(active, buffer)
+	}
+	t.runsMu.Unlock()
+
+	for _, buffer := range active {
+		_, _ = buffer.Write(p)
+	}
+}
+
+func (t *TaskContainer) activateRunLog(run *runRecord) {
+	if t.activeRunLogs == nil {
+		t.activeRunLogs = map[string]*logRingBuffer{}
+	}
+	t.activeRunLogs[run.ID] = run.buffer
+}
+
+func (t *TaskContainer) deactivateRunLog(run *runRecord) {
+	delete(t.activeRunLogs, run.ID)
+}
+
+// logRingBuffer is an in-memory, size-capped buffer of a run's captured output, with
+// support for subscribers that want to be notified of new writes as they arrive (used
+// to implement the SSE log stream).
+type logRingBuffer struct {
+	mu     sync.Mutex
+	data   []byte
+	subs   []chan []byte
+	closed bool
+}
+
+func newLogRingBuffer() *logRingBuffer {
+	return &logRingBuffer{}
+}
+
+func (b *logRingBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return len(p), nil
+	}
+
+	b.data = append(b.data, p...)
+	if len(b.data) > maxRunLogBytes {
+		b.data = b.data[len(b.data)-maxRunLogBytes:]
+	}
+
+	for _, sub := range b.subs {
+		select {
+		case sub <- append([]byte(nil), p...):
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+func (b *logRingBuffer) subscribe() chan []byte {
+	ch := make(chan []byte, 16)
+
+	b.mu.Lock()
+	b.subs = append(b.subs, ch)
+	b.mu.Unlock()
+
+	return ch
+}
+
+func (b *logRingBuffer) unsubscribe(ch chan []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sub := range b.subs {
+		if sub == ch {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			break
+		}
+	}
+}
+
+func (b *logRingBuffer) snapshot() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]byte, len(b.data))
+	copy(out, b.data)
+
+	return out
+}
+
+func (b *logRingBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, sub := range b.subs {
+		close(sub)
+	}
+	b.subs = nil
+}
@@ -0,0 +1,43 @@
+package cbcli
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HeartbeatTask lets a task report to an external monitoring endpoint around each
+// run, healthchecks.io-style: GetHeartbeatURL() is pinged with "/start" appended
+// before the run, the bare URL on success, and "/fail/<exit>" on failure.
+type HeartbeatTask interface {
+	GetHeartbeatURL() string
+}
+
+// metricsRecorder is the seam EnablePrometheus hooks into. The default, used when
+// EnablePrometheus is never called (or the binary wasn't built with the
+// cbcli_prometheus tag), is a no-op so the metrics subsystem stays entirely opt-in.
+type metricsRecorder interface {
+	observeRun(group, name, status string, duration time.Duration)
+	setRunning(group, name string, running bool)
+}
+
+type noopMetricsRecorder struct{}
+
+func (noopMetricsRecorder) observeRun(group, name, status string, duration time.Duration) {}
+
+func (noopMetricsRecorder) setRunning(group, name string, running bool) {}
+
+func (t *TaskContainer) pingHeartbeat(task Task, suffix string) {
+	heartbeatTask, ok := task.(HeartbeatTask)
+	if !ok || heartbeatTask.GetHeartbeatURL() == "" {
+		return
+	}
+
+	url := heartbeatTask.GetHeartbeatURL() + suffix
+	resp, e := http.Get(url)
+	if e != nil {
+		t.errors.Error(fmt.Errorf("cbcli: heartbeat ping %s: %w", url, e))
+		return
+	}
+	_ = resp.Body.Close()
+}
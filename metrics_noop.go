@@ -0,0 +1,12 @@
+//go:build !cbcli_prometheus
+
+package cbcli
+
+import "fmt"
+
+// EnablePrometheus requires building with the cbcli_prometheus build tag, which is
+// what pulls github.com/prometheus/client_golang into the binary. Without the tag
+// (the default), metrics stay entirely out of the build.
+func (t *TaskContainer) EnablePrometheus(addr, path string) error {
+	return fmt.Errorf("cbcli: EnablePrometheus requires building with -tags cbcli_prometheus")
+}
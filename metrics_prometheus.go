@@ -0,0 +1,72 @@
+//go:build cbcli_prometheus
+
+package cbcli
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type prometheusMetrics struct {
+	runsTotal   *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	lastSuccess *prometheus.GaugeVec
+	running     *prometheus.GaugeVec
+}
+
+func (m *prometheusMetrics) observeRun(group, name, status string, duration time.Duration) {
+	m.runsTotal.WithLabelValues(group, name, status).Inc()
+	m.duration.WithLabelValues(group, name).Observe(duration.Seconds())
+	if status == "success" {
+		m.lastSuccess.WithLabelValues(group, name).SetToCurrentTime()
+	}
+}
+
+func (m *prometheusMetrics) setRunning(group, name string, running bool) {
+	value := 0.0
+	if running {
+		value = 1
+	}
+	m.running.WithLabelValues(group, name).Set(value)
+}
+
+// EnablePrometheus registers the cbcli_task_runs_total, cbcli_task_duration_seconds,
+// cbcli_task_last_success_timestamp and cbcli_tasks_running collectors and serves
+// them on addr at path (e.g. "/metrics").
+func (t *TaskContainer) EnablePrometheus(addr, path string) error {
+	registry := prometheus.NewRegistry()
+
+	t.metrics = &prometheusMetrics{
+		runsTotal: promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+			Name: "cbcli_task_runs_total",
+			Help: "Total number of task runs by outcome.",
+		}, []string{"group", "name", "status"}),
+		duration: promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+			Name: "cbcli_task_duration_seconds",
+			Help: "Task run duration in seconds.",
+		}, []string{"group", "name"}),
+		lastSuccess: promauto.With(registry).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cbcli_task_last_success_timestamp",
+			Help: "Unix timestamp of the task's last successful run.",
+		}, []string{"group", "name"}),
+		running: promauto.With(registry).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cbcli_tasks_running",
+			Help: "Whether a task is currently running (1) or not (0).",
+		}, []string{"group", "name"}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		if e := http.ListenAndServe(addr, mux); e != nil {
+			t.errors.Error(e)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,473 @@
+package cbcli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	SchedulerInProcess    = "inprocess"
+	SchedulerSystemd      = "systemd"
+	SchedulerLaunchd      = "launchd"
+	SchedulerTaskSchedule = "taskscheduler"
+	SchedulerCrontab      = "crontab"
+)
+
+// Scheduler provisions the triggering of scheduled tasks outside of the current
+// process, as an alternative to the in-process cron runner used by DispatchTasks.
+// Implementations install/uninstall whatever native mechanism they wrap, configured
+// to invoke this binary as `os.Executable() <group> <name>`, the same invocation
+// DispatchTasks uses when forking a task.
+type Scheduler interface {
+	Name() string
+	InstallSchedules(t *TaskContainer) error
+	UninstallSchedules(t *TaskContainer) error
+}
+
+// SetScheduler selects the Scheduler used by InstallSchedules/UninstallSchedules and,
+// for non in-process schedulers, by DispatchTasks (which then skips its in-process
+// cron loop since triggering is handled externally). Passing "auto" or "" picks the
+// native scheduler for the current OS, falling back to crontab, then inprocess.
+func (t *TaskContainer) SetScheduler(name string) {
+	switch name {
+	case SchedulerInProcess:
+		t.scheduler = inProcessScheduler{}
+	case SchedulerSystemd:
+		t.scheduler = systemdScheduler{}
+	case SchedulerLaunchd:
+		t.scheduler = launchdScheduler{}
+	case SchedulerTaskSchedule:
+		t.scheduler = taskSchedulerScheduler{}
+	case SchedulerCrontab:
+		t.scheduler = crontabScheduler{}
+	case "auto", "":
+		t.scheduler = autoScheduler()
+	default:
+		t.errors.Error(fmt.Errorf("cbcli: unknown scheduler %q, falling back to inprocess", name))
+		t.scheduler = inProcessScheduler{}
+	}
+}
+
+func autoScheduler() Scheduler {
+	switch runtime.GOOS {
+	case "linux":
+		if _, e := exec.LookPath("systemctl"); e == nil {
+			return systemdScheduler{}
+		}
+		if _, e := exec.LookPath("crontab"); e == nil {
+			return crontabScheduler{}
+		}
+	case "darwin":
+		return launchdScheduler{}
+	case "windows":
+		return taskSchedulerScheduler{}
+	default:
+		if _, e := exec.LookPath("crontab"); e == nil {
+			return crontabScheduler{}
+		}
+	}
+
+	return inProcessScheduler{}
+}
+
+// InstallSchedules provisions the configured Scheduler (picking one via autoScheduler
+// if SetScheduler was never called) for every enabled scheduled task.
+func (t *TaskContainer) InstallSchedules() error {
+	if t.scheduler == nil {
+		t.scheduler = autoScheduler()
+	}
+
+	return t.scheduler.InstallSchedules(t)
+}
+
+// UninstallSchedules removes whatever InstallSchedules provisioned.
+func (t *TaskContainer) UninstallSchedules() error {
+	if t.scheduler == nil {
+		t.scheduler = autoScheduler()
+	}
+
+	return t.scheduler.UninstallSchedules(t)
+}
+
+func schedulerUnitName(group, name string) string {
+	return fmt.Sprintf("cbcli-%s-%s", group, name)
+}
+
+// inProcessScheduler is the default: DispatchTasks runs an in-process robfig/cron
+// loop, so there is nothing to provision or tear down externally.
+type inProcessScheduler struct{}
+
+func (s inProcessScheduler) Name() string { return SchedulerInProcess }
+
+func (s inProcessScheduler) InstallSchedules(t *TaskContainer) error {
+	return nil
+}
+
+func (s inProcessScheduler) UninstallSchedules(t *TaskContainer) error {
+	return nil
+}
+
+// systemdScheduler writes a .service/.timer unit pair per scheduled task into
+// ~/.config/systemd/user and enables them via `systemctl --user`.
+type systemdScheduler struct{}
+
+func (s systemdScheduler) Name() string { return SchedulerSystemd }
+
+func systemdUserDir() (string, error) {
+	home, e := os.UserHomeDir()
+	if e != nil {
+		return "", e
+	}
+
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+func (s systemdScheduler) InstallSchedules(t *TaskContainer) error {
+	dir, e := systemdUserDir()
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return e
+	}
+
+	executable, e := os.Executable()
+	if e != nil {
+		return e
+	}
+
+	for _, scheduled := range t.enabledScheduledTasks() {
+		unit := schedulerUnitName(scheduled.task.GetGroup(), scheduled.task.GetName())
+
+		onCalendar, e := cronToOnCalendar(scheduled.scheduledTask.GetSchedule())
+		if e != nil {
+			t.errors.Error(fmt.Errorf("cbcli: systemd scheduler: %s: %w", unit, e))
+			continue
+		}
+
+		serviceContents := fmt.Sprintf(
+			"[Unit]\nDescription=cbcli task %s:%s\n\n[Service]\nType=oneshot\nExecStart=%s %s %s\nEnvironment=%s\n",
+			scheduled.task.GetGroup(),
+			scheduled.task.GetName(),
+			executable,
+			scheduled.task.GetGroup(),
+			scheduled.task.GetName(),
+			strings.Join(t.buildTaskEnv(scheduled.task), " "),
+		)
+		timerContents := fmt.Sprintf(
+			"[Unit]\nDescription=cbcli timer %s:%s\n\n[Timer]\nOnCalendar=%s\nPersistent=true\n\n[Install]\nWantedBy=timers.target\n",
+			scheduled.task.GetGroup(),
+			scheduled.task.GetName(),
+			onCalendar,
+		)
+
+		if e := os.WriteFile(filepath.Join(dir, unit+".service"), []byte(serviceContents), 0644); e != nil {
+			return e
+		}
+		if e := os.WriteFile(filepath.Join(dir, unit+".timer"), []byte(timerContents), 0644); e != nil {
+			return e
+		}
+
+		if e := runCommand("systemctl", "--user", "enable", "--now", unit+".timer"); e != nil {
+			return e
+		}
+	}
+
+	return runCommand("systemctl", "--user", "daemon-reload")
+}
+
+func (s systemdScheduler) UninstallSchedules(t *TaskContainer) error {
+	dir, e := systemdUserDir()
+	if e != nil {
+		return e
+	}
+
+	for _, scheduled := range t.enabledScheduledTasks() {
+		unit := schedulerUnitName(scheduled.task.GetGroup(), scheduled.task.GetName())
+
+		_ = runCommand("systemctl", "--user", "disable", "--now", unit+".timer")
+
+		_ = os.Remove(filepath.Join(dir, unit+".service"))
+		_ = os.Remove(filepath.Join(dir, unit+".timer"))
+	}
+
+	return runCommand("systemctl", "--user", "daemon-reload")
+}
+
+// launchdScheduler writes a launchd .plist per scheduled task into
+// ~/Library/LaunchAgents and loads it via `launchctl`.
+type launchdScheduler struct{}
+
+func (s launchdScheduler) Name() string { return SchedulerLaunchd }
+
+func launchAgentsDir() (string, error) {
+	home, e := os.UserHomeDir()
+	if e != nil {
+		return "", e
+	}
+
+	return filepath.Join(home, "Library", "LaunchAgents"), nil
+}
+
+func launchdLabel(group, name string) string {
+	return fmt.Sprintf("com.cbcli.%s.%s", group, name)
+}
+
+// plistEnvironmentVariables renders a task's env as a launchd EnvironmentVariables
+// dict, or "" if there are none.
+func plistEnvironmentVariables(envs []string) string {
+	if len(envs) == 0 {
+		return ""
+	}
+
+	var dict strings.Builder
+	dict.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, env := range envs {
+		key, value, ok := strings.Cut(env, "=")
+		if !ok {
+			continue
+		}
+		dict.WriteString(fmt.Sprintf("\t\t<key>%s</key>\n\t\t<string>%s</string>\n", escapePlistText(key), escapePlistText(value)))
+	}
+	dict.WriteString("\t</dict>\n")
+
+	return dict.String()
+}
+
+func escapePlistText(s string) string {
+	replacer := strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+	)
+	return replacer.Replace(s)
+}
+
+func (s launchdScheduler) InstallSchedules(t *TaskContainer) error {
+	dir, e := launchAgentsDir()
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return e
+	}
+
+	executable, e := os.Executable()
+	if e != nil {
+		return e
+	}
+
+	for _, scheduled := range t.enabledScheduledTasks() {
+		label := launchdLabel(scheduled.task.GetGroup(), scheduled.task.GetName())
+		calendarInterval, e := cronToCalendarIntervalPlist(scheduled.scheduledTask.GetSchedule())
+		if e != nil {
+			t.errors.Error(fmt.Errorf("cbcli: launchd scheduler: %s: %w", label, e))
+			continue
+		}
+
+		plistPath := filepath.Join(dir, label+".plist")
+		contents := fmt.Sprintf(
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+
+				"<!DOCTYPE plist PUBLIC \"-//Apple//DTD PLIST 1.0//EN\" \"http://www.apple.com/DTDs/PropertyList-1.0.dtd\">\n"+
+				"<plist version=\"1.0\">\n<dict>\n"+
+				"\t<key>Label</key>\n\t<string>%s</string>\n"+
+				"\t<key>ProgramArguments</key>\n\t<array>\n\t\t<string>%s</string>\n\t\t<string>%s</string>\n\t\t<string>%s</string>\n\t</array>\n"+
+				"%s"+
+				"%s"+
+				"</dict>\n</plist>\n",
+			label,
+			executable,
+			scheduled.task.GetGroup(),
+			scheduled.task.GetName(),
+			calendarInterval,
+			plistEnvironmentVariables(t.buildTaskEnv(scheduled.task)),
+		)
+
+		if e := os.WriteFile(plistPath, []byte(contents), 0644); e != nil {
+			return e
+		}
+
+		_ = runCommand("launchctl", "unload", plistPath)
+		if e := runCommand("launchctl", "load", "-w", plistPath); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (s launchdScheduler) UninstallSchedules(t *TaskContainer) error {
+	dir, e := launchAgentsDir()
+	if e != nil {
+		return e
+	}
+
+	for _, scheduled := range t.enabledScheduledTasks() {
+		label := launchdLabel(scheduled.task.GetGroup(), scheduled.task.GetName())
+		plistPath := filepath.Join(dir, label+".plist")
+
+		_ = runCommand("launchctl", "unload", plistPath)
+		_ = os.Remove(plistPath)
+	}
+
+	return nil
+}
+
+// taskSchedulerScheduler provisions Windows Task Scheduler entries via `schtasks`.
+type taskSchedulerScheduler struct{}
+
+func (s taskSchedulerScheduler) Name() string { return SchedulerTaskSchedule }
+
+func (s taskSchedulerScheduler) InstallSchedules(t *TaskContainer) error {
+	executable, e := os.Executable()
+	if e != nil {
+		return e
+	}
+
+	for _, scheduled := range t.enabledScheduledTasks() {
+		taskName := schedulerUnitName(scheduled.task.GetGroup(), scheduled.task.GetName())
+		schedule, e := cronToSchtasksArgs(scheduled.scheduledTask.GetSchedule())
+		if e != nil {
+			t.errors.Error(fmt.Errorf("cbcli: taskscheduler: %s: %w", taskName, e))
+			continue
+		}
+
+		args := append([]string{
+			"/Create", "/F",
+			"/TN", taskName,
+			"/TR", schtasksCommand(executable, scheduled.task.GetGroup(), scheduled.task.GetName(), t.buildTaskEnv(scheduled.task)),
+		}, schedule...)
+
+		if e := runCommand("schtasks", args...); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// schtasksCommand builds the /TR command line for `schtasks /Create`. With no env it
+// is just the bare invocation; with env it wraps the invocation in
+// `cmd /C "set VAR=value&& ... && executable group name"`, since `schtasks` has no
+// dedicated flag for passing environment variables to the task it runs.
+func schtasksCommand(executable, group, name string, envs []string) string {
+	invocation := fmt.Sprintf("%s %s %s", executable, group, name)
+	if len(envs) == 0 {
+		return invocation
+	}
+
+	var sets strings.Builder
+	for _, env := range envs {
+		sets.WriteString(fmt.Sprintf("set %s&& ", env))
+	}
+
+	return fmt.Sprintf("cmd /C \"%s%s\"", sets.String(), invocation)
+}
+
+func (s taskSchedulerScheduler) UninstallSchedules(t *TaskContainer) error {
+	for _, scheduled := range t.enabledScheduledTasks() {
+		taskName := schedulerUnitName(scheduled.task.GetGroup(), scheduled.task.GetName())
+		_ = runCommand("schtasks", "/Delete", "/TN", taskName, "/F")
+	}
+
+	return nil
+}
+
+// crontabScheduler manages entries in the invoking user's crontab (via the `crontab`
+// binary), marked with a header/footer so they can be replaced wholesale on reinstall.
+type crontabScheduler struct{}
+
+func (s crontabScheduler) Name() string { return SchedulerCrontab }
+
+const (
+	crontabMarkerBegin = "# BEGIN cbcli managed tasks"
+	crontabMarkerEnd   = "# END cbcli managed tasks"
+)
+
+func (s crontabScheduler) InstallSchedules(t *TaskContainer) error {
+	executable, e := os.Executable()
+	if e != nil {
+		return e
+	}
+
+	existing := currentCrontab()
+	existing = stripCrontabBlock(existing)
+
+	var managed strings.Builder
+	managed.WriteString(crontabMarkerBegin + "\n")
+	for _, scheduled := range t.enabledScheduledTasks() {
+		managed.WriteString(fmt.Sprintf(
+			"%s %s%s %s %s\n",
+			scheduled.scheduledTask.GetSchedule(),
+			crontabEnvPrefix(t.buildTaskEnv(scheduled.task)),
+			executable,
+			scheduled.task.GetGroup(),
+			scheduled.task.GetName(),
+		))
+	}
+	managed.WriteString(crontabMarkerEnd + "\n")
+
+	return installCrontab(existing + managed.String())
+}
+
+func (s crontabScheduler) UninstallSchedules(t *TaskContainer) error {
+	existing := currentCrontab()
+	return installCrontab(stripCrontabBlock(existing))
+}
+
+// crontabEnvPrefix renders a task's env as an `env VAR=val ... ` prefix for a crontab
+// command field, or "" if there are none. Cron only applies bare `VAR=value` lines to
+// every subsequent job, so per-task env is instead applied inline via `env`.
+func crontabEnvPrefix(envs []string) string {
+	if len(envs) == 0 {
+		return ""
+	}
+
+	return "env " + strings.Join(envs, " ") + " "
+}
+
+func currentCrontab() string {
+	cmd := exec.Command("crontab", "-l")
+	out, e := cmd.Output()
+	if e != nil {
+		return ""
+	}
+
+	return string(out)
+}
+
+func stripCrontabBlock(crontab string) string {
+	beginIndex := strings.Index(crontab, crontabMarkerBegin)
+	if beginIndex == -1 {
+		return crontab
+	}
+	endIndex := strings.Index(crontab, crontabMarkerEnd)
+	if endIndex == -1 {
+		return crontab
+	}
+	endIndex += len(crontabMarkerEnd)
+
+	return crontab[:beginIndex] + crontab[endIndex:]
+}
+
+func installCrontab(contents string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(contents)
+	return cmd.Run()
+}
+
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if e := cmd.Run(); e != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), e, stderr.String())
+	}
+
+	return nil
+}